@@ -0,0 +1,102 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package okay_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/okay"
+	"github.com/google/okay/clocktest"
+)
+
+func TestDeadline(t *testing.T) {
+	table := []struct {
+		before      time.Time
+		after       time.Time
+		deadline    time.Time
+		worksBefore bool
+		worksAfter  bool
+	}{
+		{
+			before:      time.Unix(10000, 0),
+			after:       time.Unix(10010, 0),
+			deadline:    time.Unix(10005, 0),
+			worksBefore: true,
+			worksAfter:  false,
+		},
+	}
+	for _, ent := range table {
+		clock := clocktest.New(ent.before)
+		ok := okay.WithDeadlineClock(okay.New(), ent.deadline, clock)
+		if ok.Valid() != ent.worksBefore {
+			t.Errorf("Valid() behaves unexpectedly at time %v with deadline %v: got %v, want %v", clock.Now(), ent.deadline, ok.Valid(), ent.worksBefore)
+		}
+		clock.Set(ent.after)
+		if ok.Valid() != ent.worksAfter {
+			t.Errorf("Valid() behaves unexpectedly at time %v with deadline %v: got %v, want %v", clock.Now(), ent.deadline, ok.Valid(), ent.worksAfter)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	table := []struct {
+		before      time.Time
+		after       time.Time
+		timeout     time.Duration
+		worksBefore bool
+		worksAfter  bool
+	}{
+		{
+			before:      time.Unix(10000, 0),
+			after:       time.Unix(10010, 0),
+			timeout:     time.Second * 5,
+			worksBefore: true,
+			worksAfter:  false,
+		},
+	}
+	for _, ent := range table {
+		clock := clocktest.New(ent.before)
+		ok := okay.WithTimeoutClock(okay.New(), ent.timeout, clock)
+		if ok.Valid() != ent.worksBefore {
+			t.Errorf("Valid() behaves unexpectedly at time %v with timeout %v: got %v, want %v", clock.Now(), ent.timeout, ok.Valid(), ent.worksBefore)
+		}
+		clock.Set(ent.after)
+		if ok.Valid() != ent.worksAfter {
+			t.Errorf("Valid() behaves unexpectedly at time %v with timeout %v: got %v, want %v", clock.Now(), ent.timeout, ok.Valid(), ent.worksAfter)
+		}
+	}
+}
+
+// TestDeadlineParallel exercises multiple OKs backed by distinct fake clocks
+// concurrently, which raced on the old package-level timeFunc variable.
+func TestDeadlineParallel(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		i := i
+		t.Run("", func(t *testing.T) {
+			t.Parallel()
+			clock := clocktest.New(time.Unix(int64(10000+i), 0))
+			deadline := clock.Now().Add(5 * time.Second)
+			ok := okay.WithDeadlineClock(okay.New(), deadline, clock)
+			if !ok.Valid() {
+				t.Fatalf("Valid() = false before deadline")
+			}
+			clock.Advance(10 * time.Second)
+			if ok.Valid() {
+				t.Fatalf("Valid() = true after deadline")
+			}
+		})
+	}
+}
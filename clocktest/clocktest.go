@@ -0,0 +1,163 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clocktest provides a fake okay.Clock for deterministic tests, so
+// that multiple goroutines and multiple okay.OKs can be tested in parallel
+// without racing on a shared package-level variable.
+package clocktest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/okay"
+)
+
+// Clock is a fake okay.Clock whose time only moves when Set or Advance is
+// called.  The zero value is not usable; construct one with New.
+type Clock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*timer
+}
+
+// New returns a Clock whose current time is now.
+func New(now time.Time) *Clock {
+	c := &Clock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the Clock's current time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set moves the Clock to t, firing any pending timers whose deadline is now
+// at or before t.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	c.now = t
+	c.mu.Unlock()
+	c.fire()
+}
+
+// Advance moves the Clock forward by d, firing any pending timers whose
+// deadline falls at or before the new time.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	t := c.now.Add(d)
+	c.mu.Unlock()
+	c.Set(t)
+}
+
+// BlockUntil blocks until n timers are pending on the Clock, i.e. have been
+// created by AfterFunc or NewTimer but have not yet fired or been stopped.
+// It is intended to synchronize a test goroutine with background goroutines
+// that schedule timers, so that a subsequent Advance is guaranteed to see
+// them.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+func (c *Clock) fire() {
+	c.mu.Lock()
+	now := c.now
+	var due, remaining []*timer
+	for _, t := range c.timers {
+		if !t.next.After(now) {
+			due = append(due, t)
+		} else {
+			remaining = append(remaining, t)
+		}
+	}
+	c.timers = remaining
+	c.cond.Broadcast()
+	c.mu.Unlock()
+
+	for _, t := range due {
+		if t.fn != nil {
+			go t.fn()
+		} else {
+			t.ch <- now
+		}
+	}
+}
+
+// AfterFunc implements okay.Clock.
+func (c *Clock) AfterFunc(d time.Duration, f func()) okay.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &timer{c: c, next: c.now.Add(d), fn: f}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// NewTimer implements okay.Clock.
+func (c *Clock) NewTimer(d time.Duration) okay.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &timer{c: c, next: c.now.Add(d), ch: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+type timer struct {
+	c    *Clock
+	next time.Time
+	fn   func()
+	ch   chan time.Time
+}
+
+func (t *timer) C() <-chan time.Time { return t.ch }
+
+func (t *timer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	for i, o := range t.c.timers {
+		if o == t {
+			t.c.timers = append(t.c.timers[:i], t.c.timers[i+1:]...)
+			t.c.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	active := false
+	for _, o := range t.c.timers {
+		if o == t {
+			active = true
+			break
+		}
+	}
+	t.next = t.c.now.Add(d)
+	if !active {
+		t.c.timers = append(t.c.timers, t)
+	}
+	t.c.cond.Broadcast()
+	return active
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clocktest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNowAdvance(t *testing.T) {
+	start := time.Unix(10000, 0)
+	c := New(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("Now() = %v, want %v", got, start)
+	}
+	c.Advance(5 * time.Second)
+	want := start.Add(5 * time.Second)
+	if got := c.Now(); !got.Equal(want) {
+		t.Errorf("Now() after Advance = %v, want %v", got, want)
+	}
+}
+
+func TestNewTimerFires(t *testing.T) {
+	c := New(time.Unix(10000, 0))
+	timer := c.NewTimer(5 * time.Second)
+	c.Advance(4 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatalf("timer fired early")
+	default:
+	}
+	c.Advance(time.Second)
+	select {
+	case <-timer.C():
+	default:
+		t.Fatalf("timer did not fire")
+	}
+}
+
+func TestAfterFuncFires(t *testing.T) {
+	c := New(time.Unix(10000, 0))
+	done := make(chan struct{})
+	c.AfterFunc(5*time.Second, func() { close(done) })
+	c.BlockUntil(1)
+	c.Advance(5 * time.Second)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("AfterFunc callback was not invoked")
+	}
+}
+
+func TestStop(t *testing.T) {
+	c := New(time.Unix(10000, 0))
+	timer := c.NewTimer(5 * time.Second)
+	if !timer.Stop() {
+		t.Errorf("Stop() = false, want true")
+	}
+	c.Advance(10 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatalf("stopped timer fired")
+	default:
+	}
+}
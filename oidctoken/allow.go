@@ -0,0 +1,52 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidctoken
+
+import (
+	"context"
+
+	"github.com/google/okay"
+)
+
+// AllowScope returns a new okay.OK that allows any resource, as long as the
+// Claims recovered for ctx (by cfg's Extractor, via ClaimsFromContext) have
+// scope among their "scope" claim.  It is intended to be layered on top of an
+// OK already wrapped by Verify, e.g.:
+//
+//	ok := oidctoken.Verify(okay.New(), cfg)
+//	ok = oidctoken.AllowScope(ok, ctx, cfg, "files.read")
+//
+// Because okay.OK.Allows does not take a Context, the Context to check claims
+// against must be supplied up front rather than per-call.
+func AllowScope(ok okay.OK, ctx context.Context, cfg Config, scope string) okay.OK {
+	return okay.Allow(ok, func(interface{}) (bool, error) {
+		claims, has := ClaimsFromContext(ctx, cfg)
+		if !has {
+			return false, nil
+		}
+		return claims.HasScope(scope), nil
+	})
+}
+
+// AllowGroup is like AllowScope, but checks the "groups" claim instead.
+func AllowGroup(ok okay.OK, ctx context.Context, cfg Config, group string) okay.OK {
+	return okay.Allow(ok, func(interface{}) (bool, error) {
+		claims, has := ClaimsFromContext(ctx, cfg)
+		if !has {
+			return false, nil
+		}
+		return claims.InGroup(group), nil
+	})
+}
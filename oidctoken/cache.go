@@ -0,0 +1,140 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidctoken
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/okay"
+)
+
+// maxClaimsCacheEntries bounds the claims cache so that a deployment which
+// never calls ClaimsFromContext (and so never prunes an entry on read) can't
+// grow it without limit.  Once full, newly verified tokens simply aren't
+// cached; ClaimsFromContext reports ok=false for them; there is no effect on
+// Verify itself, which never consults this cache.
+const maxClaimsCacheEntries = 10000
+
+// claimsSweepInterval is how often the background sweeper prunes expired
+// entries from the claims cache.
+const claimsSweepInterval = time.Minute
+
+// claimsCache remembers the Claims most recently verified for a given raw
+// token string, so ClaimsFromContext can recover them without re-validating
+// the signature.  Entries are evicted both lazily, on access, once their
+// token has expired, and by a periodic background sweep (see startSweeper),
+// so a token that is never looked up again does not live forever.
+//
+// Entries are keyed by (issuer, audience, token) rather than token alone,
+// since the cache is process-wide (see globalClaimsCache) but a process may
+// compose multiple Configs against different issuers or audiences; without
+// the issuer and audience in the key, ClaimsFromContext for one Config could
+// return claims that were only ever validated against another.
+type claimsCache struct {
+	mu      sync.Mutex
+	entries map[claimsCacheKey]claimsEntry
+}
+
+type claimsCacheKey struct {
+	issuer   string
+	audience string
+	token    string
+}
+
+type claimsEntry struct {
+	claims  *Claims
+	expires time.Time
+}
+
+func newClaimsCache() *claimsCache {
+	return &claimsCache{entries: make(map[claimsCacheKey]claimsEntry)}
+}
+
+func (c *claimsCache) store(issuer, audience, tok string, claims *Claims, expires time.Time) {
+	key := claimsCacheKey{issuer: issuer, audience: audience, token: tok}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= maxClaimsCacheEntries {
+		return
+	}
+	c.entries[key] = claimsEntry{claims: claims, expires: expires}
+}
+
+func (c *claimsCache) load(issuer, audience, tok string, now time.Time) (*Claims, bool) {
+	key := claimsCacheKey{issuer: issuer, audience: audience, token: tok}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if c.expired(e, now) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.claims, true
+}
+
+func (c *claimsCache) expired(e claimsEntry, now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// sweep removes every entry that had already expired as of now.
+func (c *claimsCache) sweep(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		if c.expired(e, now) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+var (
+	globalClaims     *claimsCache
+	globalClaimsOnce sync.Once
+	sweeperOnce      sync.Once
+)
+
+// globalClaimsCache returns the process-wide cache shared by every verifier,
+// since ClaimsFromContext has no handle on the *verifier that produced a
+// given okay.OK; okay.OK.Allows does not take a Context, so there is no way
+// for an Allow policy to recover claims other than by re-deriving the same
+// token from the Context itself and looking it up here.  Because the cache
+// is shared across every Config in the process, entries are keyed by issuer
+// and audience as well as the token itself (see claimsCacheKey).
+func globalClaimsCache() *claimsCache {
+	globalClaimsOnce.Do(func() { globalClaims = newClaimsCache() })
+	return globalClaims
+}
+
+// startSweeper starts, at most once per process, a background goroutine that
+// periodically sweeps the global claims cache using clock.  The clock from
+// the first Verify call to run wins; this is normally DefaultClock{} in
+// production, and a clocktest.Clock in a test that wants to drive the sweep
+// deterministically.
+func startSweeper(clock okay.Clock) {
+	sweeperOnce.Do(func() {
+		go func() {
+			cache := globalClaimsCache()
+			for {
+				timer := clock.NewTimer(claimsSweepInterval)
+				<-timer.C()
+				cache.sweep(clock.Now())
+			}
+		}()
+	})
+}
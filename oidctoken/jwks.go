@@ -0,0 +1,201 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidctoken
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type discoveryDoc struct {
+	JWKSURI string `json:"jwks_uri"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwksDoc struct {
+	Keys []jwk `json:"keys"`
+}
+
+// keySet fetches and caches the signing keys published by an OIDC provider,
+// re-fetching them on demand, during a key lookup, once the cached set is
+// older than refreshInterval or lacks the requested kid, so a key rotation is
+// picked up without restarting the process.  There is no background refresh.
+type keySet struct {
+	issuer          string
+	client          *http.Client
+	refreshInterval time.Duration
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	lastRefresh time.Time
+}
+
+func newKeySet(issuer string, client *http.Client, refreshInterval time.Duration) *keySet {
+	return &keySet{
+		issuer:          issuer,
+		client:          client,
+		refreshInterval: refreshInterval,
+		keys:            make(map[string]crypto.PublicKey),
+	}
+}
+
+// key returns the public key for kid, fetching (or re-fetching, if the cache
+// is stale or kid is unknown) the JWKS as needed.
+func (k *keySet) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	k.mu.Lock()
+	key, ok := k.keys[kid]
+	stale := time.Since(k.lastRefresh) > k.refreshInterval
+	k.mu.Unlock()
+	if ok && !stale {
+		return key, nil
+	}
+	if err := k.refresh(ctx); err != nil {
+		if ok {
+			// Serve the last known key rather than fail a request outright
+			// because of a transient discovery/JWKS fetch error.
+			return key, nil
+		}
+		return nil, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	key, ok = k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("oidctoken: no key with kid %q in JWKS for issuer %q", kid, k.issuer)
+	}
+	return key, nil
+}
+
+func (k *keySet) refresh(ctx context.Context) error {
+	jwksURI, err := k.discoverJWKSURI(ctx)
+	if err != nil {
+		return err
+	}
+	doc, err := fetchJSON[jwksDoc](ctx, k.client, jwksURI)
+	if err != nil {
+		return fmt.Errorf("oidctoken: fetching JWKS: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, key := range doc.Keys {
+		pub, err := key.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[key.Kid] = pub
+	}
+	k.mu.Lock()
+	k.keys = keys
+	k.lastRefresh = time.Now()
+	k.mu.Unlock()
+	return nil
+}
+
+func (k *keySet) discoverJWKSURI(ctx context.Context) (string, error) {
+	doc, err := fetchJSON[discoveryDoc](ctx, k.client, k.issuer+"/.well-known/openid-configuration")
+	if err != nil {
+		return "", fmt.Errorf("oidctoken: fetching discovery document: %w", err)
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("oidctoken: discovery document for %q has no jwks_uri", k.issuer)
+	}
+	return doc.JWKSURI, nil
+}
+
+func fetchJSON[T any](ctx context.Context, client *http.Client, url string) (*T, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var out T
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decoding RSA modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decoding RSA exponent: %w", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decoding EC x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decoding EC y: %w", err)
+		}
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		default:
+			return nil, fmt.Errorf("unsupported EC curve %q", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidctoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// parseJWT splits tok into its header and payload, and returns the bytes
+// that were signed (header.payload) along with the decoded signature.
+func parseJWT(tok string) (header jwtHeader, payload []byte, sig []byte, signed []byte, err error) {
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 {
+		return jwtHeader{}, nil, nil, nil, fmt.Errorf("oidctoken: malformed JWT: expected 3 parts, got %d", len(parts))
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return jwtHeader{}, nil, nil, nil, fmt.Errorf("oidctoken: decoding JWT header: %w", err)
+	}
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return jwtHeader{}, nil, nil, nil, fmt.Errorf("oidctoken: parsing JWT header: %w", err)
+	}
+	payload, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return jwtHeader{}, nil, nil, nil, fmt.Errorf("oidctoken: decoding JWT payload: %w", err)
+	}
+	sig, err = base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return jwtHeader{}, nil, nil, nil, fmt.Errorf("oidctoken: decoding JWT signature: %w", err)
+	}
+	signed = []byte(parts[0] + "." + parts[1])
+	return header, payload, sig, signed, nil
+}
+
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  json.RawMessage `json:"aud"`
+	Expiry    *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+	Scope     string          `json:"scope"`
+	Groups    []string        `json:"groups"`
+}
+
+// toClaims decodes payload into a Claims.  exp and nbf are required: a token
+// that omits either is rejected here rather than treated as carrying no
+// expiry restriction, since an absent claim and an explicit zero value are
+// otherwise indistinguishable once decoded into a time.Time.
+func toClaims(payload []byte) (*Claims, error) {
+	var jc jwtClaims
+	if err := json.Unmarshal(payload, &jc); err != nil {
+		return nil, fmt.Errorf("oidctoken: parsing claims: %w", err)
+	}
+	if jc.Expiry == nil {
+		return nil, fmt.Errorf("oidctoken: token missing required exp claim")
+	}
+	if jc.NotBefore == nil {
+		return nil, fmt.Errorf("oidctoken: token missing required nbf claim")
+	}
+	var extra map[string]interface{}
+	if err := json.Unmarshal(payload, &extra); err != nil {
+		return nil, fmt.Errorf("oidctoken: parsing claims: %w", err)
+	}
+	return &Claims{
+		Issuer:    jc.Issuer,
+		Subject:   jc.Subject,
+		Audience:  decodeAudience(jc.Audience),
+		Expiry:    time.Unix(*jc.Expiry, 0),
+		NotBefore: time.Unix(*jc.NotBefore, 0),
+		Scope:     jc.Scope,
+		Groups:    jc.Groups,
+		Extra:     extra,
+	}, nil
+}
+
+// decodeAudience handles the "aud" claim being either a single string or an
+// array of strings, per the JWT spec.
+func decodeAudience(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(raw, &multi); err == nil {
+		return multi
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil && single != "" {
+		return []string{single}
+	}
+	return nil
+}
+
+// verifySignature checks sig against signed using key, per the algorithm
+// named alg.  Only the RS256 and ES256 algorithms used by common OIDC
+// providers are supported.
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("oidctoken: RS256 token signed with non-RSA key")
+		}
+		sum := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig); err != nil {
+			return fmt.Errorf("oidctoken: invalid signature: %w", err)
+		}
+		return nil
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("oidctoken: ES256 token signed with non-EC key")
+		}
+		if len(sig) != 64 {
+			return fmt.Errorf("oidctoken: malformed ES256 signature")
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		sum := sha256.Sum256(signed)
+		if !ecdsa.Verify(pub, sum[:], r, s) {
+			return fmt.Errorf("oidctoken: invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("oidctoken: unsupported signing algorithm %q", alg)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oidctoken provides an okay.OK whose Verify validates a bearer JWT
+// found in the incoming context.Context against an OpenID Connect provider's
+// discovery document and JWKS.
+package oidctoken
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/okay"
+)
+
+// TokenExtractor pulls a raw bearer token out of ctx.  It reports ok=false if
+// no token is present; that is not an error, it simply means this OK has
+// nothing to verify.
+type TokenExtractor func(ctx context.Context) (token string, ok bool)
+
+type tokenContextKey struct{}
+
+// NewContext returns a copy of ctx carrying token, for use with the default
+// TokenExtractor.  Middleware that terminates incoming requests should call
+// this once it has pulled the bearer token out of a request.
+func NewContext(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenContextKey{}, token)
+}
+
+// defaultExtractor reads the token embedded by NewContext.
+func defaultExtractor(ctx context.Context) (string, bool) {
+	tok, ok := ctx.Value(tokenContextKey{}).(string)
+	return tok, ok && tok != ""
+}
+
+// FromHeader extracts a bearer token from the "Authorization: Bearer <token>"
+// header of h.  Callers whose framework makes the incoming request's headers
+// available only through its own Context type should call FromHeader
+// themselves and pass the result to NewContext, rather than writing a custom
+// TokenExtractor.
+func FromHeader(h http.Header) (token string, ok bool) {
+	auth := h.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(auth, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(auth, prefix), true
+}
+
+// Claims holds the subset of a verified JWT's claims that policies most
+// commonly gate on.  Extra holds every claim present in the token, including
+// those already promoted to named fields.
+//
+// Expiry and NotBefore are always populated: Verify rejects any token whose
+// exp or nbf claim is missing rather than treating it as unbounded.
+type Claims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	Expiry    time.Time
+	NotBefore time.Time
+	Scope     string
+	Groups    []string
+	Extra     map[string]interface{}
+}
+
+// HasScope reports whether c's space-separated Scope claim contains scope.
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range strings.Fields(c.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// InGroup reports whether c's Groups claim contains group.
+func (c *Claims) InGroup(group string) bool {
+	for _, g := range c.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// Config configures a Verify-produced okay.OK.
+type Config struct {
+	// Issuer is the OIDC issuer URL, e.g. "https://accounts.example.com".  Its
+	// well-known discovery document is fetched from
+	// Issuer+"/.well-known/openid-configuration".
+	Issuer string
+
+	// Audience, if non-empty, must appear in a token's "aud" claim.
+	Audience string
+
+	// Extractor locates the bearer token within a Context.  Defaults to
+	// reading the token embedded by NewContext.
+	Extractor TokenExtractor
+
+	// HTTPClient is used to fetch the discovery document and JWKS.  Defaults
+	// to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// RefreshInterval is how long a fetched JWKS is used before the next
+	// verification re-fetches it; there is no background refresh.  Defaults
+	// to 1 hour.
+	RefreshInterval time.Duration
+
+	// Clock, if set, is used in place of the wall clock when checking exp/nbf
+	// and when sweeping the claims cache (see ClaimsFromContext). Primarily
+	// useful for tests; see package okay/clocktest for a fake implementation.
+	Clock okay.Clock
+}
+
+func (c *Config) setDefaults() {
+	if c.Extractor == nil {
+		c.Extractor = defaultExtractor
+	}
+	if c.HTTPClient == nil {
+		c.HTTPClient = http.DefaultClient
+	}
+	if c.RefreshInterval <= 0 {
+		c.RefreshInterval = time.Hour
+	}
+	if c.Clock == nil {
+		c.Clock = okay.DefaultClock{}
+	}
+}
+
+type verifier struct {
+	cfg  Config
+	keys *keySet
+}
+
+// Verify returns a new okay.OK that validates a bearer JWT extracted from the
+// Context passed to Verify, against cfg's OIDC provider.  It enforces issuer,
+// audience, exp, nbf, and signature, and is composable with okay.Verify,
+// okay.Allow, and okay.Validate the same way any other okay.OK is.
+//
+// Claims recovered from a successfully verified token may be recovered with
+// ClaimsFromContext, using the same Context that was passed to Verify (or
+// okay.Check).
+func Verify(ok okay.OK, cfg Config) okay.OK {
+	cfg.setDefaults()
+	startSweeper(cfg.Clock)
+	v := &verifier{
+		cfg:  cfg,
+		keys: newKeySet(cfg.Issuer, cfg.HTTPClient, cfg.RefreshInterval),
+	}
+	return okay.Verify(ok, v.verify)
+}
+
+func (v *verifier) verify(ctx context.Context) (bool, error) {
+	tok, has := v.cfg.Extractor(ctx)
+	if !has {
+		return false, nil
+	}
+	claims, err := v.validate(ctx, tok)
+	if err != nil {
+		return false, err
+	}
+	globalClaimsCache().store(v.cfg.Issuer, v.cfg.Audience, tok, claims, claims.Expiry)
+	return true, nil
+}
+
+func (v *verifier) validate(ctx context.Context, tok string) (*Claims, error) {
+	header, payload, sig, signed, err := parseJWT(tok)
+	if err != nil {
+		return nil, err
+	}
+	key, err := v.keys.key(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Algorithm, key, signed, sig); err != nil {
+		return nil, err
+	}
+	claims, err := toClaims(payload)
+	if err != nil {
+		return nil, err
+	}
+	now := v.cfg.Clock.Now()
+	if claims.Issuer != v.cfg.Issuer {
+		return nil, fmt.Errorf("oidctoken: unexpected issuer %q", claims.Issuer)
+	}
+	if v.cfg.Audience != "" && !containsStr(claims.Audience, v.cfg.Audience) {
+		return nil, fmt.Errorf("oidctoken: token does not contain audience %q", v.cfg.Audience)
+	}
+	if !now.Before(claims.Expiry) {
+		return nil, fmt.Errorf("oidctoken: token expired at %v", claims.Expiry)
+	}
+	if now.Before(claims.NotBefore) {
+		return nil, fmt.Errorf("oidctoken: token not valid until %v", claims.NotBefore)
+	}
+	return claims, nil
+}
+
+// ClaimsFromContext returns the Claims most recently verified for the bearer
+// token found in ctx by cfg's Extractor, for use by downstream Allow
+// functions.  It returns ok=false if ctx carries no token, or if the token's
+// claims have not been verified (or have since expired and been evicted).
+func ClaimsFromContext(ctx context.Context, cfg Config) (*Claims, bool) {
+	cfg.setDefaults()
+	tok, has := cfg.Extractor(ctx)
+	if !has {
+		return nil, false
+	}
+	return globalClaimsCache().load(cfg.Issuer, cfg.Audience, tok, cfg.Clock.Now())
+}
+
+func containsStr(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,366 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidctoken_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/okay"
+	"github.com/google/okay/clocktest"
+	"github.com/google/okay/oidctoken"
+)
+
+const testKeyID = "test-key"
+
+// testProvider is a minimal OIDC provider: a discovery document pointing at a
+// JWKS containing one or more RSA keys, good enough to exercise
+// keySet.refresh, discoverJWKSURI, signature verification, and key rotation
+// end to end.
+type testProvider struct {
+	*httptest.Server
+	priv *rsa.PrivateKey
+
+	mu   sync.Mutex
+	keys map[string]*rsa.PrivateKey // kid -> signing key, served in the JWKS
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() = %v", err)
+	}
+	p := &testProvider{priv: priv, keys: map[string]*rsa.PrivateKey{testKeyID: priv}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"jwks_uri": p.Server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		keys := make([]map[string]string, 0, len(p.keys))
+		for kid, priv := range p.keys {
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"n":   base64.RawURLEncoding.EncodeToString(priv.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(priv.PublicKey.E)).Bytes()),
+			})
+		}
+		p.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	})
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+// rotateKey adds a second signing key under kid to p's JWKS, alongside the
+// original testKeyID key, simulating a provider that rotates in a new key
+// without yet retiring the old one.
+func (p *testProvider) rotateKey(t *testing.T, kid string) *rsa.PrivateKey {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() = %v", err)
+	}
+	p.mu.Lock()
+	p.keys[kid] = priv
+	p.mu.Unlock()
+	return priv
+}
+
+// mintJWT signs claims as a JWT using alg and testKeyID.  Only "RS256" is
+// actually signed; for any other alg (e.g. "none") it appends an empty
+// signature segment so callers can exercise the unsupported-algorithm path.
+func (p *testProvider) mintJWT(t *testing.T, alg string, claims map[string]interface{}) string {
+	t.Helper()
+	return p.mintJWTWithKey(t, alg, testKeyID, p.priv, claims)
+}
+
+// mintJWTWithKey is like mintJWT, but signs with priv under kid, so callers
+// can mint a token under a key other than the provider's original one.
+func (p *testProvider) mintJWTWithKey(t *testing.T, alg, kid string, priv *rsa.PrivateKey, claims map[string]interface{}) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid})
+	if err != nil {
+		t.Fatalf("marshaling header: %v", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshaling claims: %v", err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	if alg != "RS256" {
+		return signed + "."
+	}
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatalf("SignPKCS1v15() = %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (p *testProvider) config(audience string) oidctoken.Config {
+	return oidctoken.Config{
+		Issuer:     p.Server.URL,
+		Audience:   audience,
+		HTTPClient: p.Server.Client(),
+	}
+}
+
+func baseClaims(issuer string) map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":    issuer,
+		"sub":    "alice",
+		"aud":    "my-service",
+		"exp":    now.Add(time.Hour).Unix(),
+		"nbf":    now.Add(-time.Minute).Unix(),
+		"scope":  "files.read files.write",
+		"groups": []string{"admins", "eng"},
+	}
+}
+
+func verify(t *testing.T, cfg oidctoken.Config, tok string) (bool, error) {
+	t.Helper()
+	ctx := oidctoken.NewContext(context.Background(), tok)
+	ok := oidctoken.Verify(okay.New(), cfg)
+	return ok.Verify(ctx)
+}
+
+func TestVerifyAcceptsValidToken(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.Close()
+	cfg := p.config("my-service")
+	tok := p.mintJWT(t, "RS256", baseClaims(p.Server.URL))
+
+	valid, err := verify(t, cfg, tok)
+	if !valid || err != nil {
+		t.Errorf("Verify() = %v, %v, want true, nil", valid, err)
+	}
+}
+
+func TestVerifyRejectsExpiredToken(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.Close()
+	cfg := p.config("my-service")
+	claims := baseClaims(p.Server.URL)
+	claims["exp"] = time.Now().Add(-time.Hour).Unix()
+	tok := p.mintJWT(t, "RS256", claims)
+
+	valid, err := verify(t, cfg, tok)
+	if valid || err == nil {
+		t.Errorf("Verify() of expired token = %v, %v, want false, non-nil", valid, err)
+	}
+}
+
+func TestVerifyRejectsWrongIssuer(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.Close()
+	cfg := p.config("my-service")
+	tok := p.mintJWT(t, "RS256", baseClaims("https://not-the-issuer.example.com"))
+
+	valid, err := verify(t, cfg, tok)
+	if valid || err == nil {
+		t.Errorf("Verify() of wrong-issuer token = %v, %v, want false, non-nil", valid, err)
+	}
+}
+
+func TestVerifyRejectsWrongAudience(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.Close()
+	cfg := p.config("not-my-service")
+	tok := p.mintJWT(t, "RS256", baseClaims(p.Server.URL))
+
+	valid, err := verify(t, cfg, tok)
+	if valid || err == nil {
+		t.Errorf("Verify() of wrong-audience token = %v, %v, want false, non-nil", valid, err)
+	}
+}
+
+func TestVerifyRejectsBadSignature(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.Close()
+	cfg := p.config("my-service")
+	tok := p.mintJWT(t, "RS256", baseClaims(p.Server.URL))
+	tok = tamperSignature(t, tok)
+
+	valid, err := verify(t, cfg, tok)
+	if valid || err == nil {
+		t.Errorf("Verify() of tampered token = %v, %v, want false, non-nil", valid, err)
+	}
+}
+
+// tamperSignature flips a bit in the middle of tok's decoded signature and
+// re-encodes it. Unlike mutating the trailing base64 character directly, this
+// can't collide back to the original signature: the last base64 group of a
+// 256-byte RSA signature only has 2 significant bits, so roughly a quarter of
+// single-character edits there decode to the same bytes.
+func tamperSignature(t *testing.T, tok string) string {
+	t.Helper()
+	i := strings.LastIndex(tok, ".")
+	if i < 0 {
+		t.Fatalf("malformed token %q", tok)
+	}
+	head, sigPart := tok[:i], tok[i+1:]
+	sig, err := base64.RawURLEncoding.DecodeString(sigPart)
+	if err != nil {
+		t.Fatalf("decoding signature: %v", err)
+	}
+	sig[len(sig)/2] ^= 0xff
+	return head + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerifyRejectsMissingExpiry(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.Close()
+	cfg := p.config("my-service")
+	claims := baseClaims(p.Server.URL)
+	delete(claims, "exp")
+	tok := p.mintJWT(t, "RS256", claims)
+
+	valid, err := verify(t, cfg, tok)
+	if valid || err == nil {
+		t.Errorf("Verify() of token missing exp = %v, %v, want false, non-nil", valid, err)
+	}
+}
+
+func TestVerifyRejectsMissingNotBefore(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.Close()
+	cfg := p.config("my-service")
+	claims := baseClaims(p.Server.URL)
+	delete(claims, "nbf")
+	tok := p.mintJWT(t, "RS256", claims)
+
+	valid, err := verify(t, cfg, tok)
+	if valid || err == nil {
+		t.Errorf("Verify() of token missing nbf = %v, %v, want false, non-nil", valid, err)
+	}
+}
+
+func TestVerifyRejectsUnsupportedAlg(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.Close()
+	cfg := p.config("my-service")
+	tok := p.mintJWT(t, "none", baseClaims(p.Server.URL))
+
+	valid, err := verify(t, cfg, tok)
+	if valid || err == nil {
+		t.Errorf("Verify() of none-alg token = %v, %v, want false, non-nil", valid, err)
+	}
+}
+
+func TestVerifyAcceptsRotatedKey(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.Close()
+	const newKeyID = "new-key"
+	newPriv := p.rotateKey(t, newKeyID)
+	cfg := p.config("my-service")
+
+	oldTok := p.mintJWT(t, "RS256", baseClaims(p.Server.URL))
+	if valid, err := verify(t, cfg, oldTok); !valid || err != nil {
+		t.Errorf("Verify() of token signed with the original key = %v, %v, want true, nil", valid, err)
+	}
+
+	newTok := p.mintJWTWithKey(t, "RS256", newKeyID, newPriv, baseClaims(p.Server.URL))
+	if valid, err := verify(t, cfg, newTok); !valid || err != nil {
+		t.Errorf("Verify() of token signed with the rotated-in key = %v, %v, want true, nil", valid, err)
+	}
+}
+
+func TestClaimsFromContextHonorsClock(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.Close()
+	clock := clocktest.New(time.Now())
+	cfg := p.config("my-service")
+	cfg.Clock = clock
+	claims := baseClaims(p.Server.URL)
+	claims["exp"] = clock.Now().Add(time.Hour).Unix()
+	tok := p.mintJWT(t, "RS256", claims)
+	ctx := oidctoken.NewContext(context.Background(), tok)
+
+	ok := oidctoken.Verify(okay.New(), cfg)
+	if valid, err := ok.Verify(ctx); !valid || err != nil {
+		t.Fatalf("Verify() = %v, %v, want true, nil", valid, err)
+	}
+	if _, has := oidctoken.ClaimsFromContext(ctx, cfg); !has {
+		t.Fatalf("ClaimsFromContext() found nothing before the fake clock advanced past exp")
+	}
+
+	clock.Set(clock.Now().Add(2 * time.Hour))
+	if _, has := oidctoken.ClaimsFromContext(ctx, cfg); has {
+		t.Errorf("ClaimsFromContext() found claims past their expiry according to cfg.Clock")
+	}
+}
+
+func TestAllowScope(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.Close()
+	cfg := p.config("my-service")
+	tok := p.mintJWT(t, "RS256", baseClaims(p.Server.URL))
+	ctx := oidctoken.NewContext(context.Background(), tok)
+
+	ok := oidctoken.Verify(okay.New(), cfg)
+	if valid, err := ok.Verify(ctx); !valid || err != nil {
+		t.Fatalf("Verify() = %v, %v, want true, nil", valid, err)
+	}
+
+	allowed, err := oidctoken.AllowScope(ok, ctx, cfg, "files.read").Allows(nil)
+	if !allowed || err != nil {
+		t.Errorf("AllowScope(%q).Allows() = %v, %v, want true, nil", "files.read", allowed, err)
+	}
+
+	denied, err := oidctoken.AllowScope(ok, ctx, cfg, "files.delete").Allows(nil)
+	if denied || err != nil {
+		t.Errorf("AllowScope(%q).Allows() = %v, %v, want false, nil", "files.delete", denied, err)
+	}
+}
+
+func TestAllowGroup(t *testing.T) {
+	p := newTestProvider(t)
+	defer p.Close()
+	cfg := p.config("my-service")
+	tok := p.mintJWT(t, "RS256", baseClaims(p.Server.URL))
+	ctx := oidctoken.NewContext(context.Background(), tok)
+
+	ok := oidctoken.Verify(okay.New(), cfg)
+	if valid, err := ok.Verify(ctx); !valid || err != nil {
+		t.Fatalf("Verify() = %v, %v, want true, nil", valid, err)
+	}
+
+	allowed, err := oidctoken.AllowGroup(ok, ctx, cfg, "eng").Allows(nil)
+	if !allowed || err != nil {
+		t.Errorf("AllowGroup(%q).Allows() = %v, %v, want true, nil", "eng", allowed, err)
+	}
+
+	denied, err := oidctoken.AllowGroup(ok, ctx, cfg, "finance").Allows(nil)
+	if denied || err != nil {
+		t.Errorf("AllowGroup(%q).Allows() = %v, %v, want false, nil", "finance", denied, err)
+	}
+}
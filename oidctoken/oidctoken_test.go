@@ -0,0 +1,74 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidctoken
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHasScope(t *testing.T) {
+	c := &Claims{Scope: "files.read files.write"}
+	if !c.HasScope("files.read") {
+		t.Errorf("HasScope(%q) = false, want true", "files.read")
+	}
+	if c.HasScope("files.delete") {
+		t.Errorf("HasScope(%q) = true, want false", "files.delete")
+	}
+}
+
+func TestInGroup(t *testing.T) {
+	c := &Claims{Groups: []string{"admins", "eng"}}
+	if !c.InGroup("eng") {
+		t.Errorf("InGroup(%q) = false, want true", "eng")
+	}
+	if c.InGroup("finance") {
+		t.Errorf("InGroup(%q) = true, want false", "finance")
+	}
+}
+
+func TestContextRoundTrip(t *testing.T) {
+	ctx := NewContext(context.Background(), "abc.def.ghi")
+	tok, ok := defaultExtractor(ctx)
+	if !ok || tok != "abc.def.ghi" {
+		t.Errorf("defaultExtractor() = %q, %v, want %q, true", tok, ok, "abc.def.ghi")
+	}
+}
+
+func TestClaimsCache(t *testing.T) {
+	c := newClaimsCache()
+	claims := &Claims{Subject: "alice"}
+	c.store("https://issuer.example.com", "my-service", "tok", claims, time.Now().Add(time.Hour))
+	got, ok := c.load("https://issuer.example.com", "my-service", "tok", time.Now())
+	if !ok || got.Subject != "alice" {
+		t.Errorf("load() = %v, %v, want Subject alice", got, ok)
+	}
+	if _, ok := c.load("https://issuer.example.com", "my-service", "missing", time.Now()); ok {
+		t.Errorf("load(%q) found an entry that was never stored", "missing")
+	}
+}
+
+func TestClaimsCacheScopedByIssuerAndAudience(t *testing.T) {
+	c := newClaimsCache()
+	claims := &Claims{Subject: "alice"}
+	c.store("https://issuer-a.example.com", "service-a", "tok", claims, time.Now().Add(time.Hour))
+	if _, ok := c.load("https://issuer-b.example.com", "service-a", "tok", time.Now()); ok {
+		t.Errorf("load() found claims stored under a different issuer")
+	}
+	if _, ok := c.load("https://issuer-a.example.com", "service-b", "tok", time.Now()); ok {
+		t.Errorf("load() found claims stored under a different audience")
+	}
+}
@@ -93,6 +93,11 @@ func (v *validOK) Valid() bool {
 	return v.v() && v.OK.Valid()
 }
 
+// Deadline forwards to the wrapped OK, implementing Deadliner when it does.
+func (v *validOK) Deadline() (time.Time, bool) {
+	return deadlineOf(v.OK)
+}
+
 // Validate returns a new OK that will call the given function every time
 // Valid() is called.  It is possible to attach many such functions by repeated
 // application of this function.  All such functions must return true for
@@ -123,6 +128,11 @@ func (v *verifyOK) Verify(ctx context.Context) (bool, error) {
 	return v.OK.Verify(ctx)
 }
 
+// Deadline forwards to the wrapped OK, implementing Deadliner when it does.
+func (v *verifyOK) Deadline() (time.Time, bool) {
+	return deadlineOf(v.OK)
+}
+
 // Verify returns a new OK that will call the given function when OK.Verify()
 // is called.  It is possible to attach multiple such functions by repeated
 // calls to this function.  Functions are called in reverse order.  The first
@@ -156,6 +166,11 @@ func (a *allowOK) Allows(i interface{}) (bool, error) {
 	return a.OK.Allows(i)
 }
 
+// Deadline forwards to the wrapped OK, implementing Deadliner when it does.
+func (a *allowOK) Deadline() (time.Time, bool) {
+	return deadlineOf(a.OK)
+}
+
 // Allow returns an OK that calls the provided function whenever OK.Allow() is
 // called.  Multiple such functions may be attached by successive calls to this
 // function.  The functions are called in reverse order.  If *any* such
@@ -185,20 +200,114 @@ func WithContext(ok OK, ctx context.Context) OK {
 	return Validate(ok, func() bool { return ctx.Err() == nil })
 }
 
-// Stubbed for testing.
-var timeFunc = time.Now
+// A Clock tells the current time, and schedules calls for the future.  It
+// exists so that WithDeadline and WithTimeout can be tested without waiting
+// on the wall clock; see package okay/clocktest for a fake implementation.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// AfterFunc waits for the duration to elapse and then calls f in its own
+	// goroutine, as with time.AfterFunc.  It returns a Timer that can be used
+	// to cancel the call.
+	AfterFunc(d time.Duration, f func()) Timer
+
+	// NewTimer creates a Timer that will send the current time on its channel
+	// after the duration elapses, as with time.NewTimer.
+	NewTimer(d time.Duration) Timer
+}
+
+// A Timer represents a single event scheduled by a Clock.
+type Timer interface {
+	// C returns the channel on which the time is delivered.  It is nil for
+	// Timers returned by Clock.AfterFunc.
+	C() <-chan time.Time
+
+	// Stop prevents the Timer from firing, as with (*time.Timer).Stop.
+	Stop() bool
+
+	// Reset changes the Timer to expire after duration d, as with
+	// (*time.Timer).Reset.
+	Reset(d time.Duration) bool
+}
+
+// DefaultClock is the Clock used by WithDeadline and WithTimeout, backed by
+// the time package.
+type DefaultClock struct{}
+
+func (DefaultClock) Now() time.Time { return time.Now() }
+
+func (DefaultClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+func (DefaultClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (r realTimer) C() <-chan time.Time { return r.Timer.C }
+
+// Deadliner is implemented by an OK that carries a known expiry time, so
+// that a caller (such as package okay/okio) can translate it into something
+// else with its own notion of a deadline, e.g. a net.Conn.
+type Deadliner interface {
+	// Deadline returns the OK's expiry, reporting ok=false if it has none.
+	Deadline() (time.Time, bool)
+}
+
+// deadlineOf reports ok's Deadline, if it has one. Every OK that wraps
+// another OK uses this to forward Deadliner through, the same way
+// http.ResponseWriter wrappers forward optional interfaces like
+// http.Flusher: embedding OK as a plain interface field only promotes
+// OK's own method set, not whatever extra methods the concrete value
+// stored in it happens to implement.
+func deadlineOf(ok OK) (time.Time, bool) {
+	d, isDeadliner := ok.(Deadliner)
+	if !isDeadliner {
+		return time.Time{}, false
+	}
+	return d.Deadline()
+}
+
+type deadlineOK struct {
+	OK
+	clock    Clock
+	deadline time.Time
+}
+
+func (d *deadlineOK) Valid() bool {
+	return d.clock.Now().Before(d.deadline) && d.OK.Valid()
+}
+
+// Deadline implements Deadliner.
+func (d *deadlineOK) Deadline() (time.Time, bool) {
+	return d.deadline, true
+}
 
 // WithDeadline returns an OK that will expire once the deadline has passed.
+// The returned OK implements Deadliner.
 func WithDeadline(ok OK, deadline time.Time) OK {
-	return Validate(ok, func() bool {
-		return timeFunc().Before(deadline)
-	})
+	return WithDeadlineClock(ok, deadline, DefaultClock{})
 }
 
-// WithTimeout returns an OK that will expire after the given duration.
+// WithDeadlineClock is like WithDeadline, but consults clock instead of the
+// wall clock.
+func WithDeadlineClock(ok OK, deadline time.Time, clock Clock) OK {
+	return &deadlineOK{OK: ok, clock: clock, deadline: deadline}
+}
+
+// WithTimeout returns an OK that will expire after the given duration. The
+// returned OK implements Deadliner.
 func WithTimeout(ok OK, timeout time.Duration) OK {
-	exp := timeFunc().Add(timeout)
-	return Validate(ok, func() bool {
-		return timeFunc().Before(exp)
-	})
+	return WithTimeoutClock(ok, timeout, DefaultClock{})
+}
+
+// WithTimeoutClock is like WithTimeout, but consults clock instead of the
+// wall clock.
+func WithTimeoutClock(ok OK, timeout time.Duration, clock Clock) OK {
+	return WithDeadlineClock(ok, clock.Now().Add(timeout), clock)
 }
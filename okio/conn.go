@@ -0,0 +1,78 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package okio
+
+import (
+	"context"
+	"net"
+
+	"github.com/google/okay"
+)
+
+type conn struct {
+	net.Conn
+	ctx context.Context
+	ok  okay.OK
+}
+
+// NewConn returns a net.Conn that calls okay.Check(ctx, resource, ok) before
+// every Read and Write, where resource is a Resource naming the operation
+// and the number of bytes involved.  Once ok becomes invalid, Read and Write
+// return okay.Invalid (or the error returned by Check) instead of touching
+// the network.
+//
+// If ok implements okay.Deadliner, its remaining deadline is applied to c via
+// SetReadDeadline and SetWriteDeadline before every Read and Write, so that
+// okay.WithTimeout and okay.WithDeadline propagate down to the socket.
+func NewConn(ctx context.Context, ok okay.OK, c net.Conn) net.Conn {
+	return &conn{Conn: c, ctx: ctx, ok: ok}
+}
+
+func (c *conn) syncDeadline() {
+	d, ok := c.ok.(okay.Deadliner)
+	if !ok {
+		return
+	}
+	t, has := d.Deadline()
+	if !has {
+		return
+	}
+	c.Conn.SetReadDeadline(t)
+	c.Conn.SetWriteDeadline(t)
+}
+
+func (c *conn) Read(p []byte) (int, error) {
+	c.syncDeadline()
+	allowed, err := okay.Check(c.ctx, Resource{Op: OpRead, N: len(p)}, c.ok)
+	if !allowed {
+		if err != nil {
+			return 0, err
+		}
+		return 0, okay.Invalid
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *conn) Write(p []byte) (int, error) {
+	c.syncDeadline()
+	allowed, err := okay.Check(c.ctx, Resource{Op: OpWrite, N: len(p)}, c.ok)
+	if !allowed {
+		if err != nil {
+			return 0, err
+		}
+		return 0, okay.Invalid
+	}
+	return c.Conn.Write(p)
+}
@@ -0,0 +1,36 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package okio adapts io.Reader, io.Writer, and net.Conn to be gated by an
+// okay.OK, checking it before every Read or Write rather than once at the
+// start of a request.
+package okio
+
+// Op names the I/O operation a Resource describes.
+type Op string
+
+const (
+	// OpRead identifies a read operation.
+	OpRead Op = "read"
+	// OpWrite identifies a write operation.
+	OpWrite Op = "write"
+)
+
+// Resource describes the I/O operation about to be attempted, and is passed
+// to okay.Check as the resource argument, so that an okay.Allow policy can
+// inspect it (e.g. to enforce a quota on N).
+type Resource struct {
+	Op Op
+	N  int
+}
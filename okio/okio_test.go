@@ -0,0 +1,181 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package okio
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/okay"
+	"github.com/google/okay/clocktest"
+)
+
+// allowAll returns an OK that verifies any context and allows any resource,
+// as a base for tests to layer more specific behavior on top of.
+func allowAll() okay.OK {
+	ok := okay.Verify(okay.New(), func(context.Context) (bool, error) { return true, nil })
+	return okay.Allow(ok, func(interface{}) (bool, error) { return true, nil })
+}
+
+// deadlineConn records the deadlines set on it, so a test can check that
+// NewConn passed the OK's deadline through to the underlying net.Conn.
+type deadlineConn struct {
+	net.Conn
+	read, write time.Time
+}
+
+func (d *deadlineConn) SetReadDeadline(t time.Time) error {
+	d.read = t
+	return d.Conn.SetReadDeadline(t)
+}
+
+func (d *deadlineConn) SetWriteDeadline(t time.Time) error {
+	d.write = t
+	return d.Conn.SetWriteDeadline(t)
+}
+
+func TestReaderBlocksOnceInvalid(t *testing.T) {
+	ok, cancel := okay.WithCancel(allowAll())
+	buf := bytes.NewBufferString("hello")
+	r := NewReader(context.Background(), ok, buf)
+
+	p := make([]byte, 5)
+	if _, err := r.Read(p); err != nil {
+		t.Fatalf("Read() before cancel = %v", err)
+	}
+
+	cancel()
+	if _, err := r.Read(p); err != okay.Invalid {
+		t.Errorf("Read() after cancel = %v, want %v", err, okay.Invalid)
+	}
+}
+
+func TestWriterBlocksOnceInvalid(t *testing.T) {
+	ok, cancel := okay.WithCancel(allowAll())
+	var buf bytes.Buffer
+	w := NewWriter(context.Background(), ok, &buf)
+
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write() before cancel = %v", err)
+	}
+
+	cancel()
+	if _, err := w.Write([]byte("world")); err != okay.Invalid {
+		t.Errorf("Write() after cancel = %v, want %v", err, okay.Invalid)
+	}
+	if buf.String() != "hello" {
+		t.Errorf("buf = %q, want %q", buf.String(), "hello")
+	}
+}
+
+func TestReaderChecksAllowsWithResource(t *testing.T) {
+	ok := okay.Verify(okay.New(), func(context.Context) (bool, error) { return true, nil })
+	ok = okay.Allow(ok, func(resource interface{}) (bool, error) {
+		r, isResource := resource.(Resource)
+		if !isResource || r.Op != OpRead {
+			return false, nil
+		}
+		return r.N <= 2, nil
+	})
+	r := NewReader(context.Background(), ok, bytes.NewBufferString("hello"))
+
+	if _, err := r.Read(make([]byte, 5)); err == nil {
+		t.Errorf("Read(5 bytes) = nil error, want non-nil")
+	}
+	if _, err := r.Read(make([]byte, 2)); err != nil {
+		t.Errorf("Read(2 bytes) = %v, want nil", err)
+	}
+}
+
+func TestConnAppliesDeadline(t *testing.T) {
+	// net.Conn deadlines are always wall-clock times, so the fake Clock here
+	// only drives okay.WithDeadlineClock's Valid() check; the deadline value
+	// itself must be a real, near-future time for SetReadDeadline to be
+	// meaningful against a real net.Conn.
+	clock := clocktest.New(time.Now())
+	deadline := time.Now().Add(time.Hour)
+	ok := okay.WithDeadlineClock(allowAll(), deadline, clock)
+
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	rec := &deadlineConn{Conn: client}
+	wrapped := NewConn(context.Background(), ok, rec)
+	go server.Write([]byte("hi"))
+
+	buf := make([]byte, 2)
+	if _, err := wrapped.Read(buf); err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+	if string(buf) != "hi" {
+		t.Errorf("Read() = %q, want %q", buf, "hi")
+	}
+	if !rec.read.Equal(deadline) || !rec.write.Equal(deadline) {
+		t.Errorf("conn deadlines = %v (read), %v (write), want %v", rec.read, rec.write, deadline)
+	}
+}
+
+func TestConnAppliesDeadlineThroughComposedOK(t *testing.T) {
+	// WithDeadline is composed with WithCancel here, the same way a real
+	// caller would combine a deadline with another combinator; the composed
+	// OK must still implement okay.Deadliner, and the deadline must still
+	// reach the conn.
+	clock := clocktest.New(time.Now())
+	deadline := time.Now().Add(time.Hour)
+	timed := okay.WithDeadlineClock(allowAll(), deadline, clock)
+	ok, cancel := okay.WithCancel(timed)
+	defer cancel()
+
+	if _, has := ok.(okay.Deadliner); !has {
+		t.Fatalf("WithCancel(WithDeadline(...)) does not implement okay.Deadliner")
+	}
+
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	rec := &deadlineConn{Conn: client}
+	wrapped := NewConn(context.Background(), ok, rec)
+	go server.Write([]byte("hi"))
+
+	buf := make([]byte, 2)
+	if _, err := wrapped.Read(buf); err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+	if string(buf) != "hi" {
+		t.Errorf("Read() = %q, want %q", buf, "hi")
+	}
+	if !rec.read.Equal(deadline) || !rec.write.Equal(deadline) {
+		t.Errorf("conn deadlines = %v (read), %v (write), want %v", rec.read, rec.write, deadline)
+	}
+}
+
+func TestConnInvalidAfterDeadline(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	ok := okay.WithDeadlineClock(allowAll(), time.Now().Add(-time.Second), clock)
+
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	wrapped := NewConn(context.Background(), ok, client)
+	if _, err := wrapped.Write([]byte("hi")); err != okay.Invalid {
+		t.Errorf("Write() after deadline = %v, want %v", err, okay.Invalid)
+	}
+}
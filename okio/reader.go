@@ -0,0 +1,47 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package okio
+
+import (
+	"context"
+	"io"
+
+	"github.com/google/okay"
+)
+
+type reader struct {
+	ctx context.Context
+	ok  okay.OK
+	r   io.Reader
+}
+
+// NewReader returns an io.Reader that calls okay.Check(ctx, resource, ok)
+// before every Read, where resource is a Resource naming the read and the
+// number of bytes requested.  Once ok becomes invalid, Read returns
+// okay.Invalid (or the error returned by Check) instead of reading from r.
+func NewReader(ctx context.Context, ok okay.OK, r io.Reader) io.Reader {
+	return &reader{ctx: ctx, ok: ok, r: r}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	allowed, err := okay.Check(r.ctx, Resource{Op: OpRead, N: len(p)}, r.ok)
+	if !allowed {
+		if err != nil {
+			return 0, err
+		}
+		return 0, okay.Invalid
+	}
+	return r.r.Read(p)
+}
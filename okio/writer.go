@@ -0,0 +1,47 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package okio
+
+import (
+	"context"
+	"io"
+
+	"github.com/google/okay"
+)
+
+type writer struct {
+	ctx context.Context
+	ok  okay.OK
+	w   io.Writer
+}
+
+// NewWriter returns an io.Writer that calls okay.Check(ctx, resource, ok)
+// before every Write, where resource is a Resource naming the write and the
+// number of bytes to be written.  Once ok becomes invalid, Write returns
+// okay.Invalid (or the error returned by Check) instead of writing to w.
+func NewWriter(ctx context.Context, ok okay.OK, w io.Writer) io.Writer {
+	return &writer{ctx: ctx, ok: ok, w: w}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	allowed, err := okay.Check(w.ctx, Resource{Op: OpWrite, N: len(p)}, w.ok)
+	if !allowed {
+		if err != nil {
+			return 0, err
+		}
+		return 0, okay.Invalid
+	}
+	return w.w.Write(p)
+}
@@ -0,0 +1,145 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package okay
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy configures VerifyWithRetry.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times Verify will be called,
+	// including the first attempt.  Defaults to 3.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the second attempt.  Defaults to
+	// 100ms.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts; each attempt after the
+	// second doubles the previous delay up to this limit.  Defaults to 10s.
+	MaxBackoff time.Duration
+
+	// Jitter is the fraction, in [0, 1], by which a delay is randomized.  A
+	// delay of d becomes a random value in [d*(1-Jitter), d*(1+Jitter)].
+	// Defaults to 0.2 when left unset (the zero value).  To disable jitter
+	// entirely and get deterministic backoff, set Jitter to a negative
+	// value, e.g. -1; setDefaults treats any negative Jitter as exactly 0,
+	// since the zero value itself can't be told apart from "unset".
+	Jitter float64
+
+	// Retryable reports whether err is worth retrying.  Defaults to treating
+	// every non-nil error as retryable; callers whose Verify can fail with a
+	// permanent error (e.g. invalid credentials) should supply a predicate
+	// that returns false for those errors, so a single bad credential does
+	// not cost MaxAttempts round trips.
+	Retryable func(error) bool
+
+	// Clock is consulted between attempts instead of the wall clock.
+	// Defaults to DefaultClock{}.
+	Clock Clock
+}
+
+func (p *RetryPolicy) setDefaults() {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 10 * time.Second
+	}
+	switch {
+	case p.Jitter < 0:
+		p.Jitter = 0
+	case p.Jitter == 0:
+		p.Jitter = 0.2
+	}
+	if p.Retryable == nil {
+		p.Retryable = func(error) bool { return true }
+	}
+	if p.Clock == nil {
+		p.Clock = DefaultClock{}
+	}
+}
+
+type retryOK struct {
+	OK
+	policy RetryPolicy
+}
+
+// VerifyWithRetry returns a new OK that retries ok's Verify according to
+// policy, honoring ctx.Done() between attempts.  It returns the last error
+// seen once every attempt has been used, once ctx is done, or as soon as an
+// error is one that policy.Retryable rejects; a later attempt that
+// succeeds after an earlier transient failure returns (true, nil) as if the
+// earlier failure never happened.  This is useful because a Verify that
+// calls out to a remote service (an OIDC provider, a revocation store) can
+// fail on a transient blip that a retry would have ridden out.
+func VerifyWithRetry(ok OK, policy RetryPolicy) OK {
+	policy.setDefaults()
+	return &retryOK{OK: ok, policy: policy}
+}
+
+func (r *retryOK) Verify(ctx context.Context) (bool, error) {
+	var lastErr error
+	backoff := r.policy.InitialBackoff
+	for attempt := 0; attempt < r.policy.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, r.policy.Clock, jitter(backoff, r.policy.Jitter)); err != nil {
+				return false, lastErr
+			}
+			backoff *= 2
+			if backoff > r.policy.MaxBackoff {
+				backoff = r.policy.MaxBackoff
+			}
+		}
+		ok, err := r.OK.Verify(ctx)
+		if err == nil {
+			return ok, nil
+		}
+		lastErr = err
+		if !r.policy.Retryable(err) {
+			return false, err
+		}
+	}
+	return false, lastErr
+}
+
+// Deadline forwards to the wrapped OK, implementing Deadliner when it does.
+func (r *retryOK) Deadline() (time.Time, bool) {
+	return deadlineOf(r.OK)
+}
+
+// sleep waits for d to elapse on clock, returning early with ctx.Err() if ctx
+// is done first.
+func sleep(ctx context.Context, clock Clock, d time.Duration) error {
+	timer := clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+func jitter(d time.Duration, frac float64) time.Duration {
+	delta := float64(d) * frac
+	return time.Duration(float64(d) - delta + rand.Float64()*2*delta)
+}
@@ -0,0 +1,202 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package okay_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/okay"
+	"github.com/google/okay/clocktest"
+)
+
+// flakyOK fails its first failures calls to Verify, then succeeds.
+type flakyOK struct {
+	okay.OK
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyOK) Verify(ctx context.Context) (bool, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return false, f.err
+	}
+	return true, nil
+}
+
+func advanceAfterBlock(clock *clocktest.Clock, n int, d time.Duration) {
+	clock.BlockUntil(n)
+	clock.Advance(d)
+}
+
+func TestVerifyWithRetrySucceedsAfterTransientFailure(t *testing.T) {
+	clock := clocktest.New(time.Unix(0, 0))
+	flaky := &flakyOK{OK: okay.New(), failures: 2, err: errors.New("transient")}
+	ok := okay.VerifyWithRetry(flaky, okay.RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		Clock:          clock,
+	})
+
+	done := make(chan struct {
+		ok  bool
+		err error
+	})
+	go func() {
+		ok, err := ok.Verify(context.Background())
+		done <- struct {
+			ok  bool
+			err error
+		}{ok, err}
+	}()
+
+	// Backoffs are jittered by up to +/-20%, so advance generously past each
+	// one: ~1s then ~2s.
+	advanceAfterBlock(clock, 1, 13*time.Second/10)
+	advanceAfterBlock(clock, 1, 25*time.Second/10)
+
+	result := <-done
+	if !result.ok || result.err != nil {
+		t.Errorf("Verify() = %v, %v, want true, nil", result.ok, result.err)
+	}
+	if flaky.calls != 3 {
+		t.Errorf("calls = %d, want 3", flaky.calls)
+	}
+}
+
+func TestVerifyWithRetryExhausted(t *testing.T) {
+	clock := clocktest.New(time.Unix(0, 0))
+	wantErr := errors.New("down for good")
+	flaky := &flakyOK{OK: okay.New(), failures: 100, err: wantErr}
+	ok := okay.VerifyWithRetry(flaky, okay.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Second,
+		Clock:          clock,
+	})
+
+	type result struct {
+		ok  bool
+		err error
+	}
+	done := make(chan result)
+	go func() {
+		ok, err := ok.Verify(context.Background())
+		done <- result{ok, err}
+	}()
+
+	advanceAfterBlock(clock, 1, 13*time.Second/10)
+	advanceAfterBlock(clock, 1, 25*time.Second/10)
+
+	r := <-done
+	if r.ok || r.err != wantErr {
+		t.Errorf("Verify() = %v, %v, want false, %v", r.ok, r.err, wantErr)
+	}
+	if flaky.calls != 3 {
+		t.Errorf("calls = %d, want 3", flaky.calls)
+	}
+}
+
+func TestVerifyWithRetryZeroJitterIsDeterministic(t *testing.T) {
+	clock := clocktest.New(time.Unix(0, 0))
+	flaky := &flakyOK{OK: okay.New(), failures: 1, err: errors.New("transient")}
+	ok := okay.VerifyWithRetry(flaky, okay.RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Second,
+		Jitter:         -1,
+		Clock:          clock,
+	})
+
+	done := make(chan struct {
+		ok  bool
+		err error
+	})
+	go func() {
+		ok, err := ok.Verify(context.Background())
+		done <- struct {
+			ok  bool
+			err error
+		}{ok, err}
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second - time.Millisecond)
+	select {
+	case <-done:
+		t.Fatalf("Verify() returned before its unjittered backoff had elapsed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	clock.Advance(time.Millisecond)
+	result := <-done
+	if !result.ok || result.err != nil {
+		t.Errorf("Verify() = %v, %v, want true, nil", result.ok, result.err)
+	}
+}
+
+func TestVerifyWithRetryNotRetryable(t *testing.T) {
+	clock := clocktest.New(time.Unix(0, 0))
+	wantErr := errors.New("invalid credentials")
+	flaky := &flakyOK{OK: okay.New(), failures: 100, err: wantErr}
+	ok := okay.VerifyWithRetry(flaky, okay.RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		Clock:          clock,
+		Retryable:      func(error) bool { return false },
+	})
+
+	got, err := ok.Verify(context.Background())
+	if got || err != wantErr {
+		t.Errorf("Verify() = %v, %v, want false, %v", got, err, wantErr)
+	}
+	if flaky.calls != 1 {
+		t.Errorf("calls = %d, want 1: a non-retryable error must not be retried", flaky.calls)
+	}
+}
+
+func TestVerifyWithRetryHonorsContextCancellation(t *testing.T) {
+	clock := clocktest.New(time.Unix(0, 0))
+	flaky := &flakyOK{OK: okay.New(), failures: 100, err: errors.New("down")}
+	ok := okay.VerifyWithRetry(flaky, okay.RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: time.Hour,
+		Clock:          clock,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	type result struct {
+		ok  bool
+		err error
+	}
+	done := make(chan result)
+	go func() {
+		ok, err := ok.Verify(ctx)
+		done <- result{ok, err}
+	}()
+
+	clock.BlockUntil(1)
+	cancel()
+
+	r := <-done
+	if r.ok || r.err == nil {
+		t.Errorf("Verify() = %v, %v, want false, non-nil", r.ok, r.err)
+	}
+	if flaky.calls != 1 {
+		t.Errorf("calls = %d, want 1: Verify should not retry once ctx is canceled", flaky.calls)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revoke
+
+import (
+	"context"
+	"fmt"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// EtcdStore is a Store backed by an etcd v3 cluster.  Revoked ids are stored
+// as keys under Prefix; a key's presence means its id is revoked.
+type EtcdStore struct {
+	Client *clientv3.Client
+	Prefix string
+}
+
+// NewEtcdStore returns a Store that keeps revoked ids as keys under prefix in
+// the etcd cluster reachable through client.
+func NewEtcdStore(client *clientv3.Client, prefix string) *EtcdStore {
+	return &EtcdStore{Client: client, Prefix: prefix}
+}
+
+func (s *EtcdStore) key(id string) string {
+	return s.Prefix + id
+}
+
+// Revoked implements Store.
+func (s *EtcdStore) Revoked(ctx context.Context, id string) (bool, error) {
+	resp, err := s.Client.Get(ctx, s.key(id))
+	if err != nil {
+		return false, fmt.Errorf("revoke: etcd get: %w", err)
+	}
+	return len(resp.Kvs) > 0, nil
+}
+
+// Revoke implements Store.
+func (s *EtcdStore) Revoke(ctx context.Context, id string) error {
+	if _, err := s.Client.Put(ctx, s.key(id), ""); err != nil {
+		return fmt.Errorf("revoke: etcd put: %w", err)
+	}
+	return nil
+}
+
+// Unrevoke implements Store.
+func (s *EtcdStore) Unrevoke(ctx context.Context, id string) error {
+	if _, err := s.Client.Delete(ctx, s.key(id)); err != nil {
+		return fmt.Errorf("revoke: etcd delete: %w", err)
+	}
+	return nil
+}
+
+// List implements Store.
+func (s *EtcdStore) List(ctx context.Context) ([]string, error) {
+	resp, err := s.Client.Get(ctx, s.Prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("revoke: etcd get: %w", err)
+	}
+	ids := make([]string, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		ids[i] = string(kv.Key[len(s.Prefix):])
+	}
+	return ids, nil
+}
+
+// Watch implements Store.  The returned channel is closed when the
+// underlying etcd watch channel closes, e.g. because the connection to the
+// cluster was lost; revoke.New reconnects by calling Watch again.
+func (s *EtcdStore) Watch(ctx context.Context) (<-chan Event, error) {
+	wch := s.Client.Watch(ctx, s.Prefix, clientv3.WithPrefix())
+	out := make(chan Event, 16)
+	go func() {
+		defer close(out)
+		for resp := range wch {
+			if err := resp.Err(); err != nil {
+				return
+			}
+			for _, ev := range resp.Events {
+				id := string(ev.Kv.Key[len(s.Prefix):])
+				out <- Event{ID: id, Revoked: ev.Type == clientv3.EventTypePut}
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revoke
+
+import (
+	"context"
+	"sync"
+)
+
+// MemStore is an in-memory Store, useful in tests and single-process
+// deployments.  The zero value is ready to use.
+type MemStore struct {
+	mu      sync.Mutex
+	revoked map[string]bool
+	subs    map[chan Event]bool
+}
+
+func (m *MemStore) init() {
+	if m.revoked == nil {
+		m.revoked = make(map[string]bool)
+		m.subs = make(map[chan Event]bool)
+	}
+}
+
+// Revoked implements Store.
+func (m *MemStore) Revoked(ctx context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.revoked[id], nil
+}
+
+// Revoke implements Store.
+func (m *MemStore) Revoke(ctx context.Context, id string) error {
+	m.set(id, true)
+	return nil
+}
+
+// Unrevoke implements Store.
+func (m *MemStore) Unrevoke(ctx context.Context, id string) error {
+	m.set(id, false)
+	return nil
+}
+
+func (m *MemStore) set(id string, revoked bool) {
+	m.mu.Lock()
+	m.init()
+	m.revoked[id] = revoked
+	var subs []chan Event
+	for ch := range m.subs {
+		subs = append(subs, ch)
+	}
+	m.mu.Unlock()
+
+	ev := Event{ID: id, Revoked: revoked}
+	for _, ch := range subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// List implements Store.
+func (m *MemStore) List(ctx context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for id, revoked := range m.revoked {
+		if revoked {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Watch implements Store.
+func (m *MemStore) Watch(ctx context.Context) (<-chan Event, error) {
+	m.mu.Lock()
+	m.init()
+	ch := make(chan Event, 16)
+	m.subs[ch] = true
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		delete(m.subs, ch)
+		m.mu.Unlock()
+		close(ch)
+	}()
+	return ch, nil
+}
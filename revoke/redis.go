@@ -0,0 +1,128 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revoke
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore is a Store backed by a Redis set plus a pub/sub channel used to
+// notify other watchers of changes.  Set and Channel default to "revoked" and
+// "revoked:changes" if left empty.
+type RedisStore struct {
+	Client  *redis.Client
+	Set     string
+	Channel string
+}
+
+// NewRedisStore returns a Store that keeps revoked ids in a Redis set and
+// announces changes over a pub/sub channel, using client.
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{Client: client}
+}
+
+func (s *RedisStore) set() string {
+	if s.Set == "" {
+		return "revoked"
+	}
+	return s.Set
+}
+
+func (s *RedisStore) channel() string {
+	if s.Channel == "" {
+		return "revoked:changes"
+	}
+	return s.Channel
+}
+
+// Revoked implements Store.
+func (s *RedisStore) Revoked(ctx context.Context, id string) (bool, error) {
+	ok, err := s.Client.SIsMember(ctx, s.set(), id).Result()
+	if err != nil {
+		return false, fmt.Errorf("revoke: redis SISMEMBER: %w", err)
+	}
+	return ok, nil
+}
+
+// Revoke implements Store.
+func (s *RedisStore) Revoke(ctx context.Context, id string) error {
+	return s.publish(ctx, id, true)
+}
+
+// Unrevoke implements Store.
+func (s *RedisStore) Unrevoke(ctx context.Context, id string) error {
+	return s.publish(ctx, id, false)
+}
+
+func (s *RedisStore) publish(ctx context.Context, id string, revoked bool) error {
+	pipe := s.Client.TxPipeline()
+	if revoked {
+		pipe.SAdd(ctx, s.set(), id)
+	} else {
+		pipe.SRem(ctx, s.set(), id)
+	}
+	msg := id
+	if revoked {
+		msg = "+" + id
+	} else {
+		msg = "-" + id
+	}
+	pipe.Publish(ctx, s.channel(), msg)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("revoke: redis: %w", err)
+	}
+	return nil
+}
+
+// List implements Store.
+func (s *RedisStore) List(ctx context.Context) ([]string, error) {
+	ids, err := s.Client.SMembers(ctx, s.set()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("revoke: redis SMEMBERS: %w", err)
+	}
+	return ids, nil
+}
+
+// Watch implements Store.  The returned channel is closed when ctx is done,
+// or when the subscription's channel closes, e.g. because the connection to
+// Redis was lost; revoke.New reconnects by calling Watch again.
+func (s *RedisStore) Watch(ctx context.Context) (<-chan Event, error) {
+	sub := s.Client.Subscribe(ctx, s.channel())
+	msgs := sub.Channel()
+	out := make(chan Event, 16)
+	go func() {
+		defer close(out)
+		defer sub.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, open := <-msgs:
+				if !open {
+					return
+				}
+				if msg.Payload == "" {
+					continue
+				}
+				revoked := msg.Payload[0] == '+'
+				out <- Event{ID: msg.Payload[1:], Revoked: revoked}
+			}
+		}
+	}()
+	return out, nil
+}
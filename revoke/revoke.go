@@ -0,0 +1,213 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package revoke provides an okay.OK that becomes invalid as soon as its
+// subject is revoked in a shared store, such as etcd or Redis.  Unlike
+// okay.WithCancel, a revocation made on one process is observed by every
+// other process watching the same store, which makes it possible to cancel
+// an OK that was handed out by a different node.
+package revoke
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/okay"
+)
+
+// Event describes a change to a single id's revocation status, as delivered
+// by Store.Watch.
+type Event struct {
+	ID      string
+	Revoked bool
+}
+
+// A Store holds the set of currently revoked ids, shared across processes.
+type Store interface {
+	// Revoked reports whether id is currently revoked.
+	Revoked(ctx context.Context, id string) (bool, error)
+
+	// Revoke marks id as revoked.
+	Revoke(ctx context.Context, id string) error
+
+	// Unrevoke clears any revocation of id.
+	Unrevoke(ctx context.Context, id string) error
+
+	// List returns every id currently revoked.
+	List(ctx context.Context) ([]string, error)
+
+	// Watch streams every change made to the revoked set until ctx is done,
+	// at which point the returned channel is closed.  Watch may also close
+	// the channel on its own, e.g. after a connection failure; a caller that
+	// wants to keep watching should call Watch again.
+	Watch(ctx context.Context) (<-chan Event, error)
+}
+
+// A Revoker administers the revoked set backing one or more OKs returned by
+// New.
+type Revoker struct {
+	store Store
+}
+
+// NewRevoker returns a Revoker backed by store.
+func NewRevoker(store Store) *Revoker {
+	return &Revoker{store: store}
+}
+
+// Revoke marks id as revoked in the underlying Store.
+func (r *Revoker) Revoke(ctx context.Context, id string) error {
+	return r.store.Revoke(ctx, id)
+}
+
+// Unrevoke clears any revocation of id in the underlying Store.
+func (r *Revoker) Unrevoke(ctx context.Context, id string) error {
+	return r.store.Unrevoke(ctx, id)
+}
+
+// List returns every id currently revoked in the underlying Store.
+func (r *Revoker) List(ctx context.Context) ([]string, error) {
+	return r.store.List(ctx)
+}
+
+const (
+	initialBackoff = 100 * time.Millisecond
+	maxBackoff     = 30 * time.Second
+)
+
+type watcher struct {
+	revoked int32 // atomic; 1 once id has been observed revoked
+	clock   okay.Clock
+}
+
+func (w *watcher) valid() bool {
+	return atomic.LoadInt32(&w.revoked) == 0
+}
+
+func (w *watcher) setRevoked(revoked bool) {
+	if revoked {
+		atomic.StoreInt32(&w.revoked, 1)
+	} else {
+		atomic.StoreInt32(&w.revoked, 0)
+	}
+}
+
+// New returns an okay.OK whose Valid reports false as soon as id appears in
+// store's revoked set, along with a CancelFunc that stops the background
+// watcher and any outstanding watch held open against store. Callers that
+// mint an OK per request or session should call the CancelFunc once that OK
+// is no longer needed, the same way they would for okay.WithCancel.
+//
+// The watcher's calls to store are derived from ctx, so canceling ctx itself
+// also stops the watcher; the returned CancelFunc is independent of ctx and
+// exists for callers that want to bound the watcher's lifetime without tying
+// it to a longer-lived parent context.
+//
+// New maintains the current state locally, updated by a background watcher
+// that reconnects with exponential backoff if its connection to store is
+// interrupted, so that Valid never blocks on store.
+//
+// New makes one synchronous attempt to establish the watch against store,
+// so that a Revoke made immediately after New returns is never missed; if
+// that attempt fails, it falls back to the same backoff used to recover from
+// a later disconnection.
+func New(ctx context.Context, store Store, id string) (okay.OK, okay.CancelFunc) {
+	return NewClock(ctx, store, id, okay.DefaultClock{})
+}
+
+// NewClock is like New, but consults clock instead of the wall clock when
+// backing off between reconnection attempts.  Primarily useful for tests.
+func NewClock(ctx context.Context, store Store, id string, clock okay.Clock) (okay.OK, okay.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &watcher{clock: clock}
+	ch, err := store.Watch(ctx)
+	if err == nil {
+		w.refresh(ctx, store, id)
+	}
+	go w.run(ctx, store, id, ch)
+	return okay.Validate(okay.New(), w.valid), okay.CancelFunc(cancel)
+}
+
+// refresh sets w's state to match store's current record for id, leaving the
+// state unchanged if the lookup fails.
+func (w *watcher) refresh(ctx context.Context, store Store, id string) {
+	revoked, err := store.Revoked(ctx, id)
+	if err != nil {
+		return
+	}
+	w.setRevoked(revoked)
+}
+
+// run consumes ch, an already-established watch channel (or nil, if the
+// initial connection attempt failed), applying its events to w's state. Each
+// time the channel closes without ctx being done, run backs off and retries
+// store.Watch in place, re-synchronizing w's state before resuming
+// consumption, until ctx is done.
+//
+// This is a single loop rather than consume/reconnect calling each other,
+// so a long-lived watcher behind a store that disconnects repeatedly does
+// not grow its goroutine's stack without bound.
+func (w *watcher) run(ctx context.Context, store Store, id string, ch <-chan Event) {
+	backoff := initialBackoff
+	for {
+		if ch != nil {
+			for ev := range ch {
+				if ev.ID == id {
+					w.setRevoked(ev.Revoked)
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			backoff = initialBackoff
+		}
+		if err := w.sleep(ctx, jitter(backoff)); err != nil {
+			return
+		}
+		backoff = nextBackoff(backoff)
+		var err error
+		ch, err = store.Watch(ctx)
+		if err != nil {
+			ch = nil
+			continue
+		}
+		w.refresh(ctx, store, id)
+	}
+}
+
+// sleep waits for d to elapse on w.clock, returning early with ctx.Err() if
+// ctx is done first.
+func (w *watcher) sleep(ctx context.Context, d time.Duration) error {
+	timer := w.clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+func nextBackoff(d time.Duration) time.Duration {
+	d *= 2
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+func jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d/2+1)))
+}
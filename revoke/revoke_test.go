@@ -0,0 +1,133 @@
+// Copyright 2017 Google
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revoke
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/okay/clocktest"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("condition never became true")
+}
+
+func TestNewValidUntilRevoked(t *testing.T) {
+	store := &MemStore{}
+	ok, cancel := New(context.Background(), store, "user-1")
+	defer cancel()
+	if !ok.Valid() {
+		t.Fatalf("Valid() = false, want true before revocation")
+	}
+	if err := NewRevoker(store).Revoke(context.Background(), "user-1"); err != nil {
+		t.Fatalf("Revoke() = %v", err)
+	}
+	waitFor(t, func() bool { return !ok.Valid() })
+}
+
+func TestNewIgnoresOtherIDs(t *testing.T) {
+	store := &MemStore{}
+	ok, cancel := New(context.Background(), store, "user-1")
+	defer cancel()
+	if err := NewRevoker(store).Revoke(context.Background(), "user-2"); err != nil {
+		t.Fatalf("Revoke() = %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if !ok.Valid() {
+		t.Fatalf("Valid() = false, want true: revoking a different id must not affect this OK")
+	}
+}
+
+func TestUnrevoke(t *testing.T) {
+	store := &MemStore{}
+	r := NewRevoker(store)
+	ctx := context.Background()
+	if err := r.Revoke(ctx, "user-1"); err != nil {
+		t.Fatalf("Revoke() = %v", err)
+	}
+	ok, cancel := New(context.Background(), store, "user-1")
+	defer cancel()
+	waitFor(t, func() bool { return !ok.Valid() })
+
+	if err := r.Unrevoke(ctx, "user-1"); err != nil {
+		t.Fatalf("Unrevoke() = %v", err)
+	}
+	waitFor(t, func() bool { return ok.Valid() })
+}
+
+// flakyStore wraps a MemStore whose first failures calls to Watch fail
+// outright, to exercise the reconnection loop in watcher.run.
+type flakyStore struct {
+	*MemStore
+	mu       sync.Mutex
+	failures int
+}
+
+func (f *flakyStore) Watch(ctx context.Context) (<-chan Event, error) {
+	f.mu.Lock()
+	if f.failures > 0 {
+		f.failures--
+		f.mu.Unlock()
+		return nil, errors.New("flakyStore: watch failed")
+	}
+	f.mu.Unlock()
+	return f.MemStore.Watch(ctx)
+}
+
+func TestReconnectWithBackoff(t *testing.T) {
+	store := &flakyStore{MemStore: &MemStore{}, failures: 1}
+	clock := clocktest.New(time.Now())
+	ok, cancel := NewClock(context.Background(), store, "user-1", clock)
+	defer cancel()
+
+	// The initial Watch in NewClock fails, so watcher.run backs off, sleeping
+	// on clock before retrying Watch.
+	clock.BlockUntil(1)
+	clock.Advance(initialBackoff)
+
+	if err := NewRevoker(store.MemStore).Revoke(context.Background(), "user-1"); err != nil {
+		t.Fatalf("Revoke() = %v", err)
+	}
+	waitFor(t, func() bool { return !ok.Valid() })
+}
+
+func TestList(t *testing.T) {
+	store := &MemStore{}
+	r := NewRevoker(store)
+	ctx := context.Background()
+	r.Revoke(ctx, "user-1")
+	r.Revoke(ctx, "user-2")
+	r.Unrevoke(ctx, "user-2")
+
+	ids, err := r.List(ctx)
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "user-1" {
+		t.Errorf("List() = %v, want [user-1]", ids)
+	}
+}